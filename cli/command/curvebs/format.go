@@ -0,0 +1,159 @@
+/*
+ *  Copyright (c) 2021 NetEase Inc.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+/*
+ * Project: CurveAdm
+ * Created Date: 2026-07-28
+ * Author: Jingli Chen (Wine93)
+ */
+
+package curvebs
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/opencurve/curveadm/cli/cli"
+	"github.com/opencurve/curveadm/internal/configure"
+	"github.com/opencurve/curveadm/internal/task/task/bs"
+	"github.com/spf13/cobra"
+)
+
+const (
+	formatExample = `Examples:
+  $ curveadm format -f format.yaml            # format devices listed in format.yaml
+  $ curveadm format -f format.yaml --reload   # reconcile fstab/UUID records after disk swaps`
+)
+
+type formatOptions struct {
+	filename string
+	reload   bool
+}
+
+// NewFormatCommand creates the `curveadm format` command: it formats every
+// device in the given format config into a chunkfile pool, or, with
+// --reload, reconciles stale fstab/UUID records left behind by a disk swap.
+func NewFormatCommand(curveadm *cli.CurveAdm) *cobra.Command {
+	options := formatOptions{}
+
+	cmd := &cobra.Command{
+		Use:     "format",
+		Short:   "Format chunkfile pool",
+		Args:    cobra.NoArgs,
+		Example: formatExample,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runFormat(curveadm, options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVarP(&options.filename, "filename", "f", "format.yaml", "Specify the format config file")
+	flags.BoolVar(&options.reload, "reload", false, "Reconcile stale fstab/UUID records instead of formatting")
+
+	return cmd
+}
+
+func runFormat(curveadm *cli.CurveAdm, options formatOptions) error {
+	fcs, err := configure.ParseFormat(options.filename)
+	if err != nil {
+		return err
+	}
+
+	if options.reload {
+		return runFormatReload(curveadm, fcs)
+	}
+	return runFormatStart(curveadm, fcs)
+}
+
+// runFormatStart dispatches every FormatConfig entry at once; it's the
+// per-host concurrency slot acquired inside NewFormatChunkfilePoolTask's
+// task (see acquireFormatSlot) that actually caps how many of them run
+// against a given host simultaneously, blocking the rest until a slot frees
+// up. Errors are collected rather than aborting the sweep, so one device
+// failing doesn't stop the others from finishing.
+func runFormatStart(curveadm *cli.CurveAdm, fcs []*configure.FormatConfig) error {
+	var wg sync.WaitGroup
+	errs := make([]error, len(fcs))
+	for i, fc := range fcs {
+		wg.Add(1)
+		go func(i int, fc *configure.FormatConfig) {
+			defer wg.Done()
+			t, err := bs.NewFormatChunkfilePoolTask(curveadm, fc)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			errs[i] = t.Execute()
+		}(i, fc)
+	}
+	wg.Wait()
+
+	failed := []string{}
+	for i, err := range errs {
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("device=%s: %v", fcs[i].GetDevice(), err))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("format failed for %d device(s):\n%s", len(failed), strings.Join(failed, "\n"))
+	}
+	return nil
+}
+
+// runFormatReload runs the reload sweep across every FormatConfig entry,
+// recording a task's error against its own ReloadResult rather than
+// aborting the sweep, so one unreachable host can't discard the results
+// already collected for every other device swapped on the fleet.
+func runFormatReload(curveadm *cli.CurveAdm, fcs []*configure.FormatConfig) error {
+	tasks, results, err := bs.BuildFormatReloadTasks(curveadm, fcs)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for i, t := range tasks {
+		if err := t.Execute(); err != nil {
+			results[i].Error = err
+			failed++
+		}
+	}
+
+	printFormatReloadReport(results)
+	if failed > 0 {
+		return fmt.Errorf("reload failed for %d of %d device(s), see report above", failed, len(results))
+	}
+	return nil
+}
+
+// printFormatReloadReport renders one row per FormatConfig entry: what the
+// reload sweep did (or didn't) to its fstab record, or the error that kept
+// it from finishing.
+func printFormatReloadReport(results []*bs.ReloadResult) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "HOST\tDEVICE\tMOUNTPOINT\tACTION\tOLD UUID\tNEW UUID")
+	for _, r := range results {
+		action := r.Action
+		if r.Error != nil {
+			action = fmt.Sprintf("error: %v", r.Error)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			r.Host, r.Device, r.MountPoint, action, r.OldUUID, r.NewUUID)
+	}
+	w.Flush()
+}
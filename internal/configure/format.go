@@ -0,0 +1,89 @@
+/*
+ *  Copyright (c) 2021 NetEase Inc.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+/*
+ * Project: CurveAdm
+ * Created Date: 2026-07-28
+ * Author: Jingli Chen (Wine93)
+ */
+
+package configure
+
+import (
+	"io/ioutil"
+
+	"github.com/mitchellh/mapstructure"
+	"gopkg.in/yaml.v2"
+)
+
+// FormatConfig describes one device to be formatted into a chunkfile pool:
+// which host/device/mountPoint it targets, how much of the device to use,
+// which image runs the format job, and the optional cgroup CPU/IO limits and
+// per-host concurrency cap applied to that job.
+type FormatConfig struct {
+	Host           string `mapstructure:"host"`
+	Device         string `mapstructure:"device"`
+	MountPoint     string `mapstructure:"mountpoint"`
+	FormatPercent  int    `mapstructure:"format_percent"`
+	ContainerImage string `mapstructure:"container_image"`
+
+	// Optional cgroup CPU/IO limits applied to the format container. A zero
+	// value (or empty string) leaves the corresponding docker flag unset.
+	CPULimit          string `mapstructure:"cpu_limit"`
+	CPUShares         int    `mapstructure:"cpu_shares"`
+	BlkioWeight       int    `mapstructure:"blkio_weight"`
+	ReadBpsDevice     string `mapstructure:"read_bps_device"`
+	WriteBpsDevice    string `mapstructure:"write_bps_device"`
+	FormatConcurrency int    `mapstructure:"format_concurrency"`
+}
+
+func (fc *FormatConfig) GetHost() string           { return fc.Host }
+func (fc *FormatConfig) GetDevice() string         { return fc.Device }
+func (fc *FormatConfig) GetMountPoint() string     { return fc.MountPoint }
+func (fc *FormatConfig) GetFormatPercent() int     { return fc.FormatPercent }
+func (fc *FormatConfig) GetContainerImage() string { return fc.ContainerImage }
+
+func (fc *FormatConfig) GetCPULimit() string       { return fc.CPULimit }
+func (fc *FormatConfig) GetCPUShares() int         { return fc.CPUShares }
+func (fc *FormatConfig) GetBlkioWeight() int       { return fc.BlkioWeight }
+func (fc *FormatConfig) GetReadBpsDevice() string  { return fc.ReadBpsDevice }
+func (fc *FormatConfig) GetWriteBpsDevice() string { return fc.WriteBpsDevice }
+func (fc *FormatConfig) GetFormatConcurrency() int { return fc.FormatConcurrency }
+
+// ParseFormat reads a format config file (a YAML list of per-device format
+// entries) and decodes it into a slice of FormatConfig, used by both
+// `curveadm format` and `curveadm format --reload`.
+func ParseFormat(filename string) ([]*FormatConfig, error) {
+	bytes, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	items := []map[string]interface{}{}
+	if err := yaml.Unmarshal(bytes, &items); err != nil {
+		return nil, err
+	}
+
+	fcs := make([]*FormatConfig, 0, len(items))
+	for _, item := range items {
+		fc := &FormatConfig{}
+		if err := mapstructure.Decode(item, fc); err != nil {
+			return nil, err
+		}
+		fcs = append(fcs, fc)
+	}
+	return fcs, nil
+}
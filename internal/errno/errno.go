@@ -0,0 +1,70 @@
+/*
+ *  Copyright (c) 2021 NetEase Inc.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+/*
+ * Project: CurveAdm
+ * Created Date: 2026-07-29
+ * Author: Jingli Chen (Wine93)
+ */
+
+package errno
+
+import "fmt"
+
+// ErrorCode pairs a stable numeric code with a human-readable description,
+// optionally decorated with call-site detail via F. Every "errno.ERR_*"
+// value returned by a task step is an ErrorCode, so callers always get both
+// a code they can grep for and a message with the specifics of what failed.
+type ErrorCode struct {
+	code        int
+	description string
+	detail      string
+}
+
+// EC declares a new error code. Codes are never reused, so grepping a code
+// number always finds exactly one definition.
+func EC(code int, description string) ErrorCode {
+	return ErrorCode{code: code, description: description}
+}
+
+// F attaches formatted detail (e.g. "host=%s device=%s") to the error
+// without losing its code/description. It returns a copy so the
+// package-level ERR_* values stay immutable across concurrent use.
+func (ec ErrorCode) F(format string, args ...interface{}) ErrorCode {
+	ec.detail = fmt.Sprintf(format, args...)
+	return ec
+}
+
+// Error implements the error interface so ErrorCode can be returned
+// directly from step.LambdaType and friends.
+func (ec ErrorCode) Error() string {
+	if ec.detail == "" {
+		return fmt.Sprintf("[%d] %s", ec.code, ec.description)
+	}
+	return fmt.Sprintf("[%d] %s: %s", ec.code, ec.description, ec.detail)
+}
+
+var (
+	ERR_NOT_A_BLOCK_DEVICE     = EC(10060, "device is not a block device")
+	ERR_GET_DEVICE_UUID_FAILED = EC(10061, "get device uuid failed")
+
+	// format concurrency errors: acquireFormatSlot requires every
+	// FormatConfig entry routed to the same host to agree on its
+	// concurrency limit, and bounds how long it will block waiting for a
+	// slot to free up.
+	ERR_FORMAT_CONCURRENCY_LIMIT_MISMATCH = EC(10070, "format concurrency limit mismatch")
+	ERR_FORMAT_CONCURRENCY_LIMIT_EXCEEDED = EC(10071, "format concurrency limit exceeded")
+)
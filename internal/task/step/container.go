@@ -0,0 +1,113 @@
+/*
+ *  Copyright (c) 2021 NetEase Inc.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+/*
+ * Project: CurveAdm
+ * Created Date: 2026-07-28
+ * Author: Jingli Chen (Wine93)
+ */
+
+package step
+
+import (
+	"fmt"
+
+	"github.com/opencurve/curveadm/internal/task/context"
+)
+
+// Volume is a bind mount from a path on the host into the container.
+type Volume struct {
+	HostPath      string
+	ContainerPath string
+}
+
+// ExecOptions carries the SSH/local execution context a step runs its
+// underlying command with.
+type ExecOptions struct {
+	ExecWithSudo   bool
+	ExecInLocal    bool
+	ExecTimeoutSec int
+}
+
+// CreateContainer creates (but does not start) a docker container.
+type CreateContainer struct {
+	Image      string
+	Command    string
+	Entrypoint string
+	Name       string
+	Remove     bool
+	Volumes    []Volume
+
+	// Optional cgroup CPU/IO limits, left unset (no docker flag emitted)
+	// when the corresponding field is zero/empty.
+	CPULimit       string
+	CPUShares      int
+	BlkioWeight    int
+	ReadBpsDevice  string
+	WriteBpsDevice string
+
+	Out         *string
+	ExecOptions ExecOptions
+}
+
+// buildArgs assembles the "docker create" argument list, including the
+// optional cgroup CPU/IO limit flags.
+func (s *CreateContainer) buildArgs() []string {
+	args := []string{"create"}
+	if s.Remove {
+		args = append(args, "--rm")
+	}
+	if len(s.Name) > 0 {
+		args = append(args, "--name", s.Name)
+	}
+	if len(s.Entrypoint) > 0 {
+		args = append(args, "--entrypoint", s.Entrypoint)
+	}
+	if len(s.CPULimit) > 0 {
+		args = append(args, "--cpus", s.CPULimit)
+	}
+	if s.CPUShares > 0 {
+		args = append(args, "--cpu-shares", fmt.Sprintf("%d", s.CPUShares))
+	}
+	if s.BlkioWeight > 0 {
+		args = append(args, "--blkio-weight", fmt.Sprintf("%d", s.BlkioWeight))
+	}
+	if len(s.ReadBpsDevice) > 0 {
+		args = append(args, "--device-read-bps", s.ReadBpsDevice)
+	}
+	if len(s.WriteBpsDevice) > 0 {
+		args = append(args, "--device-write-bps", s.WriteBpsDevice)
+	}
+	for _, volume := range s.Volumes {
+		args = append(args, "-v", fmt.Sprintf("%s:%s", volume.HostPath, volume.ContainerPath))
+	}
+	args = append(args, s.Image)
+	if len(s.Command) > 0 {
+		args = append(args, s.Command)
+	}
+	return args
+}
+
+func (s *CreateContainer) Execute(ctx *context.Context) error {
+	out, err := ctx.Module().Shell().Command("docker", s.buildArgs(), s.ExecOptions).Execute(ctx)
+	if err != nil {
+		return err
+	}
+	if s.Out != nil {
+		*s.Out = out
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+/*
+ *  Copyright (c) 2021 NetEase Inc.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+/*
+ * Project: CurveAdm
+ * Created Date: 2026-07-29
+ * Author: Jingli Chen (Wine93)
+ */
+
+package step
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCreateContainerBuildArgsOmitsUnsetCgroupLimits(t *testing.T) {
+	s := &CreateContainer{Image: "curvebs-format"}
+	args := strings.Join(s.buildArgs(), " ")
+
+	for _, flag := range []string{"--cpus", "--cpu-shares", "--blkio-weight", "--device-read-bps", "--device-write-bps"} {
+		if strings.Contains(args, flag) {
+			t.Errorf("buildArgs() = %q, want no %s when the corresponding field is unset", args, flag)
+		}
+	}
+}
+
+func TestCreateContainerBuildArgsIncludesSetCgroupLimits(t *testing.T) {
+	s := &CreateContainer{
+		Image:          "curvebs-format",
+		CPULimit:       "2.5",
+		CPUShares:      512,
+		BlkioWeight:    300,
+		ReadBpsDevice:  "/dev/sdb:100mb",
+		WriteBpsDevice: "/dev/sdb:50mb",
+	}
+	args := s.buildArgs()
+
+	cases := []struct {
+		flag  string
+		value string
+	}{
+		{"--cpus", "2.5"},
+		{"--cpu-shares", "512"},
+		{"--blkio-weight", "300"},
+		{"--device-read-bps", "/dev/sdb:100mb"},
+		{"--device-write-bps", "/dev/sdb:50mb"},
+	}
+	for _, c := range cases {
+		if !containsPair(args, c.flag, c.value) {
+			t.Errorf("buildArgs() = %v, want %s followed by %q", args, c.flag, c.value)
+		}
+	}
+}
+
+func containsPair(args []string, flag, value string) bool {
+	for i := 0; i+1 < len(args); i++ {
+		if args[i] == flag && args[i+1] == value {
+			return true
+		}
+	}
+	return false
+}
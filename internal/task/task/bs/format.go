@@ -50,6 +50,14 @@ const (
 
 	// 82511eb8-e4e3-4a50-a736-d584fbf533fa
 	REEGX_DEVICE_UUID = "^.{8}-.{4}-.{4}-.{4}-.{12}$"
+
+	FORMAT_CONTAINER_NAME_PREFIX = "curvebs-format-"
+
+	RELOAD_ACTION_NONE      = "none"
+	RELOAD_ACTION_ADDED     = "added"
+	RELOAD_ACTION_REMOVED   = "removed"
+	RELOAD_ACTION_REWRITTEN = "rewritten"
+	RELOAD_ACTION_DUPLICATE = "duplicate"
 )
 
 type (
@@ -62,6 +70,33 @@ type (
 		skipAdd    bool
 		curveadm   *cli.CurveAdm
 	}
+
+	// ReloadResult reports what, if anything, a `curveadm format --reload`
+	// sweep did for one FormatConfig entry. Error is set when the entry's
+	// task failed (Action keeps whatever reconcile decided before the
+	// failure, if it got that far), so one unreachable host doesn't erase
+	// the report for every other entry in the sweep.
+	ReloadResult struct {
+		Host       string
+		Device     string
+		MountPoint string
+		Action     string // none|added|removed|rewritten|duplicate
+		OldUUID    string
+		NewUUID    string
+		Error      error
+	}
+
+	step2ReloadFSTab struct {
+		host       string
+		device     string
+		mountPoint string
+		deviceUUID string
+		deviceOk   bool
+		fstab      string
+		allUUIDs   string
+		out        *ReloadResult
+		curveadm   *cli.CurveAdm
+	}
 )
 
 func skipFormat(containerId *string) step.LambdaType {
@@ -94,11 +129,22 @@ func checkDeviceUUID(host, device string, success *bool, uuid *string) step.Lamb
 	}
 }
 
+// fstabDelExpression/fstabAddExpression build the sed expressions used to
+// remove and (re)write a "# GENERATED BY CURVEADM" fstab record, shared by
+// step2EditFSTab and step2ReloadFSTab so the two never drift apart.
+func fstabDelExpression(uuid string) string {
+	return fmt.Sprintf("/UUID=%s/d", uuid)
+}
+
+func fstabAddExpression(uuid, mountPoint string) string {
+	return fmt.Sprintf("$ a UUID=%s  %s  ext4  rw,errors=remount-ro  0  0  # %s",
+		uuid, mountPoint, WARNING_EDIT)
+}
+
 func (s *step2EditFSTab) expression(express2del, express2add *string) step.LambdaType {
 	return func(ctx *context.Context) error {
-		*express2del = fmt.Sprintf("/UUID=%s/d", *s.oldUUID)
-		*express2add = fmt.Sprintf("$ a UUID=%s  %s  ext4  rw,errors=remount-ro  0  0  # %s",
-			s.uuid, s.mountPoint, WARNING_EDIT)
+		*express2del = fstabDelExpression(*s.oldUUID)
+		*express2add = fstabAddExpression(s.uuid, s.mountPoint)
 		return nil
 	}
 }
@@ -162,8 +208,329 @@ func (s *step2EditFSTab) Execute(ctx *context.Context) error {
 	})
 }
 
+func setDeviceOk(uuid *string, ok *bool) step.LambdaType {
+	return func(ctx *context.Context) error {
+		*ok = len(*uuid) > 0 &&
+			!strings.Contains(*uuid, SIGNATURE_NOT_A_BLOCK_DEVICE) &&
+			regexp.MustCompile(REEGX_DEVICE_UUID).MatchString(*uuid)
+		return nil
+	}
+}
+
+// fstabRecords finds every "# GENERATED BY CURVEADM" line for s.mountPoint.
+// Normally there's at most one; more than one means a prior bug, a manual
+// edit, or a reload racing a format run left duplicate records behind, and
+// the caller must surface that rather than silently reconciling against
+// whichever one a single match happened to pick.
+func (s *step2ReloadFSTab) fstabRecords() []string {
+	pattern := regexp.MustCompile(fmt.Sprintf(
+		`(?m)^UUID=([0-9a-fA-F-]+)\s+%s\s+.*# %s\s*$`,
+		regexp.QuoteMeta(s.mountPoint), regexp.QuoteMeta(WARNING_EDIT)))
+	matches := pattern.FindAllStringSubmatch(s.fstab, -1)
+	uuids := make([]string, 0, len(matches))
+	for _, m := range matches {
+		uuids = append(uuids, m[1])
+	}
+	return uuids
+}
+
+func (s *step2ReloadFSTab) uuidOnHost(uuid string) bool {
+	for _, line := range strings.Fields(s.allUUIDs) {
+		if line == uuid {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *step2ReloadFSTab) reconcile(express2del, express2add *string) step.LambdaType {
+	return func(ctx *context.Context) error {
+		records := s.fstabRecords()
+		found := len(records) > 0
+		var oldUUID string
+		if found {
+			oldUUID = records[0]
+		}
+
+		s.out.Host = s.host
+		s.out.Device = s.device
+		s.out.MountPoint = s.mountPoint
+		s.out.OldUUID = oldUUID
+		s.out.NewUUID = ""
+		if s.deviceOk {
+			s.out.NewUUID = s.deviceUUID
+		}
+
+		switch {
+		case len(records) > 1:
+			// Ambiguous: don't guess which record is authoritative, just report it.
+			s.out.Action = RELOAD_ACTION_DUPLICATE
+			s.out.OldUUID = strings.Join(records, ",")
+			s.out.NewUUID = ""
+		case found && s.deviceOk && oldUUID == s.deviceUUID:
+			s.out.Action = RELOAD_ACTION_NONE
+		case found && s.deviceOk:
+			s.out.Action = RELOAD_ACTION_REWRITTEN
+			*express2del = fstabDelExpression(oldUUID)
+			*express2add = fstabAddExpression(s.deviceUUID, s.mountPoint)
+		case found && !s.uuidOnHost(oldUUID):
+			s.out.Action = RELOAD_ACTION_REMOVED
+			*express2del = fstabDelExpression(oldUUID)
+		case !found && s.deviceOk:
+			s.out.Action = RELOAD_ACTION_ADDED
+			*express2add = fstabAddExpression(s.deviceUUID, s.mountPoint)
+		default:
+			s.out.Action = RELOAD_ACTION_NONE
+		}
+		return nil
+	}
+}
+
+func (s *step2ReloadFSTab) execute(ctx *context.Context) error {
+	var express2del, express2add string
+	curveadm := s.curveadm
+	now := time.Now().Format("2006-01-02")
+	steps := []task.Step{}
+
+	steps = append(steps, &step.CopyFile{ // backup fstab, idempotent per day
+		Source:      os.GetFSTabPath(),
+		Dest:        fmt.Sprintf("%s-%s.backup", os.GetFSTabPath(), now),
+		NoClobber:   true,
+		ExecOptions: curveadm.ExecOptions(),
+	})
+	steps = append(steps, &step.Command{ // current fstab content
+		Command:     fmt.Sprintf("cat %s", os.GetFSTabPath()),
+		Out:         &s.fstab,
+		ExecOptions: curveadm.ExecOptions(),
+	})
+	steps = append(steps, &step.Command{ // uuid of every block device present on the host
+		Command:     "blkid -s UUID -o value",
+		Out:         &s.allUUIDs,
+		ExecOptions: curveadm.ExecOptions(),
+	})
+	steps = append(steps, &step.BlockId{ // uuid of the device this entry refers to, if still present
+		Device:      s.device,
+		Format:      "value",
+		MatchTag:    "UUID",
+		Out:         &s.deviceUUID,
+		ExecOptions: curveadm.ExecOptions(),
+	})
+	steps = append(steps, &step.Lambda{
+		Lambda: setDeviceOk(&s.deviceUUID, &s.deviceOk),
+	})
+	steps = append(steps, &step.Lambda{ // decide none|added|removed|rewritten|duplicate
+		Lambda: s.reconcile(&express2del, &express2add),
+	})
+
+	for _, step := range steps {
+		err := step.Execute(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(express2del) > 0 {
+		if err := (&step.Sed{
+			Files:       []string{os.GetFSTabPath()},
+			Expression:  &express2del,
+			InPlace:     true,
+			ExecOptions: curveadm.ExecOptions(),
+		}).Execute(ctx); err != nil {
+			return err
+		}
+	}
+	if len(express2add) > 0 {
+		if err := (&step.Sed{
+			Files:       []string{os.GetFSTabPath()},
+			Expression:  &express2add,
+			InPlace:     true,
+			ExecOptions: curveadm.ExecOptions(),
+		}).Execute(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *step2ReloadFSTab) Execute(ctx *context.Context) error {
+	// lock by memstorage, same as step2EditFSTab
+	return s.curveadm.MemStorage().TX(func(m *utils.SafeMap) error {
+		return s.execute(ctx)
+	})
+}
+
+// NewFormatReloadTask reconciles one FormatConfig entry's fstab record against
+// the host's current block devices: a dangling UUID left behind by a disk
+// swap is dropped, a present-but-unlisted device gets registered, and a
+// device that was reformatted under a new UUID has its record rewritten. The
+// caller runs this once per FormatConfig and reads out after the task
+// completes to build its report; BuildFormatReloadTasks does exactly that
+// for a whole FormatConfig list.
+func NewFormatReloadTask(curveadm *cli.CurveAdm, fc *configure.FormatConfig, out *ReloadResult) (*task.Task, error) {
+	host := fc.GetHost()
+	hc, err := curveadm.GetHost(host)
+	if err != nil {
+		return nil, err
+	}
+
+	device := fc.GetDevice()
+	mountPoint := fc.GetMountPoint()
+	subname := fmt.Sprintf("host=%s device=%s mountPoint=%s", host, device, mountPoint)
+	t := task.NewTask("Reload Format FSTab", subname, hc.GetSSHConfig())
+
+	t.AddStep(&step2ReloadFSTab{
+		host:       host,
+		device:     device,
+		mountPoint: mountPoint,
+		out:        out,
+		curveadm:   curveadm,
+	})
+
+	return t, nil
+}
+
+// BuildFormatReloadTasks builds one reload task per FormatConfig entry and
+// returns them paired with the ReloadResult each will populate once run. This
+// is what the `curveadm format --reload` command (cli/command/curvebs) loops
+// over: it executes the tasks one after another and, once they've run,
+// prints results as a table report.
+func BuildFormatReloadTasks(curveadm *cli.CurveAdm, fcs []*configure.FormatConfig) ([]*task.Task, []*ReloadResult, error) {
+	tasks := make([]*task.Task, 0, len(fcs))
+	results := make([]*ReloadResult, 0, len(fcs))
+	for _, fc := range fcs {
+		result := &ReloadResult{}
+		t, err := NewFormatReloadTask(curveadm, fc, result)
+		if err != nil {
+			return nil, nil, err
+		}
+		tasks = append(tasks, t)
+		results = append(results, result)
+	}
+	return tasks, results, nil
+}
+
 func device2ContainerName(device string) string {
-	return fmt.Sprintf("curvebs-format-%s", utils.MD5Sum(device))
+	return fmt.Sprintf("%s%s", FORMAT_CONTAINER_NAME_PREFIX, utils.MD5Sum(device))
+}
+
+// deviceBpsLimit turns a user-specified BPS cap into the "device:rate" form
+// required by `docker run --device-{read,write}-bps`. A cap that already
+// names a device (contains ":") is passed through untouched; a bare rate is
+// applied against the device being formatted.
+func deviceBpsLimit(device, limit string) string {
+	if len(limit) == 0 || strings.Contains(limit, ":") {
+		return limit
+	}
+	return fmt.Sprintf("%s:%s", device, limit)
+}
+
+// FORMAT_CONCURRENCY_ACQUIRE_TIMEOUT bounds how long acquireFormatSlot will
+// wait for a free slot before giving up. Without a bound, a host stuck at its
+// concurrency cap (e.g. because an earlier run leaked a slot, or is just
+// legitimately busy) would hang every subsequent `format` call forever.
+const FORMAT_CONCURRENCY_ACQUIRE_TIMEOUT = 30 * time.Minute
+
+func formatConcurrencyCountKey(host string) string {
+	return fmt.Sprintf("format/concurrency/%s/count", host)
+}
+
+func formatConcurrencyLimitKey(host string) string {
+	return fmt.Sprintf("format/concurrency/%s/limit", host)
+}
+
+// acquireFormatSlot reserves one of the host's formatConcurrency slots,
+// guarded by MemStorage().TX so concurrent NewFormatChunkfilePoolTask runs
+// can't all read a stale count and all proceed (the check must be
+// check-and-increment under the same lock, not a re-query of docker state).
+// It blocks, polling the counter, until a slot frees up or
+// FORMAT_CONCURRENCY_ACQUIRE_TIMEOUT elapses.
+//
+// All FormatConfig entries for the same host share one counter, so the first
+// entry to reach this host for the process wins the limit that governs it;
+// a later entry asking for a different limit is a configuration conflict and
+// fails loudly instead of silently racing against the first.
+func acquireFormatSlot(curveadm *cli.CurveAdm, host string, limit int) step.LambdaType {
+	return func(ctx *context.Context) error {
+		if limit <= 0 {
+			return nil
+		}
+		countKey := formatConcurrencyCountKey(host)
+		limitKey := formatConcurrencyLimitKey(host)
+		deadline := time.Now().Add(FORMAT_CONCURRENCY_ACQUIRE_TIMEOUT)
+		for {
+			acquired := false
+			err := curveadm.MemStorage().TX(func(m *utils.SafeMap) error {
+				effectiveLimit, ok := m.Get(limitKey).(int)
+				if !ok {
+					effectiveLimit = limit
+					m.Set(limitKey, effectiveLimit)
+				} else if effectiveLimit != limit {
+					return errno.ERR_FORMAT_CONCURRENCY_LIMIT_MISMATCH.
+						F("host=%s limit=%d conflictsWithLimit=%d", host, limit, effectiveLimit)
+				}
+
+				n, _ := m.Get(countKey).(int)
+				if n < effectiveLimit {
+					m.Set(countKey, n+1)
+					acquired = true
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			if acquired {
+				return nil
+			}
+			if time.Now().After(deadline) {
+				return errno.ERR_FORMAT_CONCURRENCY_LIMIT_EXCEEDED.
+					F("host=%s limit=%d waited=%s", host, limit, FORMAT_CONCURRENCY_ACQUIRE_TIMEOUT)
+			}
+			time.Sleep(time.Second)
+		}
+	}
+}
+
+// releaseFormatSlot gives back the slot reserved by acquireFormatSlot.
+func releaseFormatSlot(curveadm *cli.CurveAdm, host string, limit int) step.LambdaType {
+	return func(ctx *context.Context) error {
+		if limit <= 0 {
+			return nil
+		}
+		key := formatConcurrencyCountKey(host)
+		return curveadm.MemStorage().TX(func(m *utils.SafeMap) error {
+			n, _ := m.Get(key).(int)
+			if n > 0 {
+				m.Set(key, n-1)
+			}
+			return nil
+		})
+	}
+}
+
+// step2FormatWithConcurrencyLimit wraps the mkfs/mount/container steps of a
+// format run so the concurrency slot acquired before them is released via
+// defer on every exit path (step failure, panic, or success), instead of
+// only after the last step in a flat list happens to succeed.
+type step2FormatWithConcurrencyLimit struct {
+	host     string
+	limit    int
+	curveadm *cli.CurveAdm
+	steps    []task.Step
+}
+
+func (s *step2FormatWithConcurrencyLimit) Execute(ctx *context.Context) error {
+	if err := acquireFormatSlot(s.curveadm, s.host, s.limit)(ctx); err != nil {
+		return err
+	}
+	defer releaseFormatSlot(s.curveadm, s.host, s.limit)(ctx)
+
+	for _, step := range s.steps {
+		if err := step.Execute(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func NewFormatChunkfilePoolTask(curveadm *cli.CurveAdm, fc *configure.FormatConfig) (*task.Task, error) {
@@ -177,8 +544,36 @@ func NewFormatChunkfilePoolTask(curveadm *cli.CurveAdm, fc *configure.FormatConf
 	device := fc.GetDevice()
 	mountPoint := fc.GetMountPoint()
 	usagePercent := fc.GetFormatPercent()
+	cpuLimit := fc.GetCPULimit()
+	cpuShares := fc.GetCPUShares()
+	blkioWeight := fc.GetBlkioWeight()
+	readBpsDevice := deviceBpsLimit(device, fc.GetReadBpsDevice())
+	writeBpsDevice := deviceBpsLimit(device, fc.GetWriteBpsDevice())
+	formatConcurrency := fc.GetFormatConcurrency()
+	limits := []string{}
+	if len(cpuLimit) > 0 {
+		limits = append(limits, fmt.Sprintf("cpus=%s", cpuLimit))
+	}
+	if cpuShares > 0 {
+		limits = append(limits, fmt.Sprintf("cpuShares=%d", cpuShares))
+	}
+	if blkioWeight > 0 {
+		limits = append(limits, fmt.Sprintf("blkioWeight=%d", blkioWeight))
+	}
+	if len(readBpsDevice) > 0 {
+		limits = append(limits, fmt.Sprintf("readBps=%s", readBpsDevice))
+	}
+	if len(writeBpsDevice) > 0 {
+		limits = append(limits, fmt.Sprintf("writeBps=%s", writeBpsDevice))
+	}
+	if formatConcurrency > 0 {
+		limits = append(limits, fmt.Sprintf("concurrency=%d", formatConcurrency))
+	}
 	subname := fmt.Sprintf("host=%s device=%s mountPoint=%s usage=%d%%",
 		fc.GetHost(), device, mountPoint, usagePercent)
+	if len(limits) > 0 {
+		subname = fmt.Sprintf("%s limits={%s}", subname, strings.Join(limits, ","))
+	}
 	t := task.NewTask("Start Format Chunkfile Pool", subname, hc.GetSSHConfig())
 
 	// add step to task
@@ -203,64 +598,81 @@ func NewFormatChunkfilePoolTask(curveadm *cli.CurveAdm, fc *configure.FormatConf
 	t.AddStep(&step.Lambda{
 		Lambda: skipFormat(&oldContainerId),
 	})
-	// 2: mkfs, mount device, edit fstab
-	t.AddStep(&step.BlockId{
-		Device:      device,
-		Format:      "value",
-		MatchTag:    "UUID",
-		Out:         &oldUUID,
-		ExecOptions: curveadm.ExecOptions(),
-	})
-	t.AddStep(&step.UmountFilesystem{
-		Directorys:     []string{device},
-		IgnoreUmounted: true,
-		IgnoreNotFound: true,
-		ExecOptions:    curveadm.ExecOptions(),
-	})
-	t.AddStep(&step.CreateDirectory{
-		Paths:       []string{mountPoint},
-		ExecOptions: curveadm.ExecOptions(),
-	})
-	t.AddStep(&step.CreateFilesystem{ // mkfs.ext4 MOUNT_POINT
-		Device:      device,
-		ExecOptions: curveadm.ExecOptions(),
-	})
-	t.AddStep(&step.MountFilesystem{
-		Source:      device,
-		Directory:   mountPoint,
-		ExecOptions: curveadm.ExecOptions(),
-	})
-	t.AddStep(&step2EditFSTab{
-		host:       host,
-		device:     device,
-		oldUUID:    &oldUUID,
-		mountPoint: mountPoint,
-		curveadm:   curveadm,
-	})
-	// 3: run container to format chunkfile pool
-	t.AddStep(&step.PullImage{
-		Image:       fc.GetContainerImage(),
-		ExecOptions: curveadm.ExecOptions(),
-	})
-	t.AddStep(&step.CreateContainer{
-		Image:       fc.GetContainerImage(),
-		Command:     formatCommand,
-		Entrypoint:  "/bin/bash",
-		Name:        containerName,
-		Remove:      true,
-		Volumes:     []step.Volume{{HostPath: mountPoint, ContainerPath: chunkfilePoolRootDir}},
-		Out:         &containerId,
-		ExecOptions: curveadm.ExecOptions(),
-	})
-	t.AddStep(&step.InstallFile{
-		ContainerId:       &containerId,
-		ContainerDestPath: formatScriptPath,
-		Content:           &formatScript,
-		ExecOptions:       curveadm.ExecOptions(),
-	})
-	t.AddStep(&step.StartContainer{
-		ContainerId: &containerId,
-		ExecOptions: curveadm.ExecOptions(),
+	// 2: mkfs, mount device, edit fstab, format; wrapped so the formatConcurrency
+	// slot reserved up front is always released, even if a step below fails
+	t.AddStep(&step2FormatWithConcurrencyLimit{
+		host:     host,
+		limit:    formatConcurrency,
+		curveadm: curveadm,
+		steps: []task.Step{
+			&step.BlockId{
+				Device:      device,
+				Format:      "value",
+				MatchTag:    "UUID",
+				Out:         &oldUUID,
+				ExecOptions: curveadm.ExecOptions(),
+			},
+			&step.UmountFilesystem{
+				Directorys:     []string{device},
+				IgnoreUmounted: true,
+				IgnoreNotFound: true,
+				ExecOptions:    curveadm.ExecOptions(),
+			},
+			&step.CreateDirectory{
+				Paths:       []string{mountPoint},
+				ExecOptions: curveadm.ExecOptions(),
+			},
+			&step.CreateFilesystem{ // mkfs.ext4 MOUNT_POINT
+				Device:      device,
+				ExecOptions: curveadm.ExecOptions(),
+			},
+			&step.MountFilesystem{
+				Source:      device,
+				Directory:   mountPoint,
+				ExecOptions: curveadm.ExecOptions(),
+			},
+			&step2EditFSTab{
+				host:       host,
+				device:     device,
+				oldUUID:    &oldUUID,
+				mountPoint: mountPoint,
+				curveadm:   curveadm,
+			},
+			// 3: run container to format chunkfile pool
+			&step.PullImage{
+				Image:       fc.GetContainerImage(),
+				ExecOptions: curveadm.ExecOptions(),
+			},
+			&step.CreateContainer{
+				Image:          fc.GetContainerImage(),
+				Command:        formatCommand,
+				Entrypoint:     "/bin/bash",
+				Name:           containerName,
+				Remove:         true,
+				Volumes:        []step.Volume{{HostPath: mountPoint, ContainerPath: chunkfilePoolRootDir}},
+				CPULimit:       cpuLimit,
+				CPUShares:      cpuShares,
+				BlkioWeight:    blkioWeight,
+				ReadBpsDevice:  readBpsDevice,
+				WriteBpsDevice: writeBpsDevice,
+				Out:            &containerId,
+				ExecOptions:    curveadm.ExecOptions(),
+			},
+			&step.InstallFile{
+				ContainerId:       &containerId,
+				ContainerDestPath: formatScriptPath,
+				Content:           &formatScript,
+				ExecOptions:       curveadm.ExecOptions(),
+			},
+			&step.StartContainer{
+				ContainerId: &containerId,
+				ExecOptions: curveadm.ExecOptions(),
+			},
+			&step.WaitContainer{ // block until the format job finishes, so the slot reflects it
+				ContainerId: &containerId,
+				ExecOptions: curveadm.ExecOptions(),
+			},
+		},
 	})
 
 	return t, nil
@@ -0,0 +1,243 @@
+/*
+ *  Copyright (c) 2021 NetEase Inc.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+/*
+ * Project: CurveAdm
+ * Created Date: 2026-07-29
+ * Author: Jingli Chen (Wine93)
+ */
+
+package bs
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/opencurve/curveadm/cli/cli"
+	"github.com/opencurve/curveadm/internal/task/context"
+	"github.com/opencurve/curveadm/internal/task/step"
+	"github.com/opencurve/curveadm/internal/task/task"
+	"github.com/opencurve/curveadm/internal/utils"
+)
+
+func TestDeviceBpsLimit(t *testing.T) {
+	cases := []struct {
+		name   string
+		device string
+		limit  string
+		want   string
+	}{
+		{"empty limit passes through", "/dev/sdb", "", ""},
+		{"bare rate applies to the device", "/dev/sdb", "100mb", "/dev/sdb:100mb"},
+		{"already-qualified limit passes through", "/dev/sdb", "/dev/sdc:50mb", "/dev/sdc:50mb"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := deviceBpsLimit(c.device, c.limit); got != c.want {
+				t.Errorf("deviceBpsLimit(%q, %q) = %q, want %q", c.device, c.limit, got, c.want)
+			}
+		})
+	}
+}
+
+func TestStep2ReloadFSTabFstabRecordsAndUUIDOnHost(t *testing.T) {
+	s := &step2ReloadFSTab{
+		mountPoint: "/data/chunkserver0",
+		fstab: "UUID=aaaa-1111  /data/chunkserver0  ext4  rw,errors=remount-ro  0  0  # " + WARNING_EDIT + "\n" +
+			"UUID=bbbb-2222  /data/chunkserver1  ext4  rw,errors=remount-ro  0  0  # " + WARNING_EDIT + "\n",
+		allUUIDs: "aaaa-1111\ncccc-3333\n",
+	}
+
+	records := s.fstabRecords()
+	if len(records) != 1 || records[0] != "aaaa-1111" {
+		t.Fatalf("fstabRecords() = %v, want [aaaa-1111]", records)
+	}
+
+	if !s.uuidOnHost("aaaa-1111") {
+		t.Error("uuidOnHost(aaaa-1111) = false, want true")
+	}
+	if s.uuidOnHost("bbbb-2222") {
+		t.Error("uuidOnHost(bbbb-2222) = true, want false")
+	}
+}
+
+func TestStep2ReloadFSTabFstabRecordsDuplicate(t *testing.T) {
+	s := &step2ReloadFSTab{
+		mountPoint: "/data/chunkserver0",
+		fstab: "UUID=aaaa-1111  /data/chunkserver0  ext4  rw,errors=remount-ro  0  0  # " + WARNING_EDIT + "\n" +
+			"UUID=dddd-4444  /data/chunkserver0  ext4  rw,errors=remount-ro  0  0  # " + WARNING_EDIT + "\n",
+	}
+
+	records := s.fstabRecords()
+	if len(records) != 2 {
+		t.Fatalf("fstabRecords() = %v, want 2 records for the same mountPoint", records)
+	}
+}
+
+// TestStep2ReloadFSTabReconcile walks the full none/added/removed/rewritten/
+// duplicate action matrix that BuildFormatReloadTasks/runFormatReload builds
+// its report from; each case only sets the fields reconcile actually reads,
+// matching what execute() would have populated via blkid/cat/setDeviceOk.
+func TestStep2ReloadFSTabReconcile(t *testing.T) {
+	cases := []struct {
+		name          string
+		fstab         string
+		allUUIDs      string
+		deviceUUID    string
+		deviceOk      bool
+		wantAction    string
+		wantDel       bool
+		wantAdd       bool
+		wantNoNewUUID bool
+	}{
+		{
+			name:       "no record, device absent: nothing to do",
+			wantAction: RELOAD_ACTION_NONE,
+		},
+		{
+			name:       "no record, device present: add it",
+			deviceUUID: "aaaa-1111",
+			deviceOk:   true,
+			wantAction: RELOAD_ACTION_ADDED,
+			wantAdd:    true,
+		},
+		{
+			name:       "record matches current device: nothing to do",
+			fstab:      "UUID=aaaa-1111  /mnt  ext4  rw,errors=remount-ro  0  0  # " + WARNING_EDIT + "\n",
+			deviceUUID: "aaaa-1111",
+			deviceOk:   true,
+			wantAction: RELOAD_ACTION_NONE,
+		},
+		{
+			name:       "record stale, device reformatted under a new UUID: rewrite",
+			fstab:      "UUID=aaaa-1111  /mnt  ext4  rw,errors=remount-ro  0  0  # " + WARNING_EDIT + "\n",
+			deviceUUID: "bbbb-2222",
+			deviceOk:   true,
+			wantAction: RELOAD_ACTION_REWRITTEN,
+			wantDel:    true,
+			wantAdd:    true,
+		},
+		{
+			name:       "record dangling, device gone from the host entirely: remove",
+			fstab:      "UUID=aaaa-1111  /mnt  ext4  rw,errors=remount-ro  0  0  # " + WARNING_EDIT + "\n",
+			allUUIDs:   "cccc-3333\n",
+			deviceOk:   false,
+			wantAction: RELOAD_ACTION_REMOVED,
+			wantDel:    true,
+		},
+		{
+			name: "two generated records for the same mountpoint: ambiguous",
+			fstab: "UUID=aaaa-1111  /mnt  ext4  rw,errors=remount-ro  0  0  # " + WARNING_EDIT + "\n" +
+				"UUID=bbbb-2222  /mnt  ext4  rw,errors=remount-ro  0  0  # " + WARNING_EDIT + "\n",
+			deviceUUID:    "aaaa-1111",
+			deviceOk:      true,
+			wantAction:    RELOAD_ACTION_DUPLICATE,
+			wantNoNewUUID: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			out := &ReloadResult{}
+			s := &step2ReloadFSTab{
+				mountPoint: "/mnt",
+				fstab:      c.fstab,
+				allUUIDs:   c.allUUIDs,
+				deviceUUID: c.deviceUUID,
+				deviceOk:   c.deviceOk,
+				out:        out,
+			}
+
+			var del, add string
+			if err := s.reconcile(&del, &add)(nil); err != nil {
+				t.Fatalf("reconcile() error = %v", err)
+			}
+			if out.Action != c.wantAction {
+				t.Errorf("Action = %q, want %q", out.Action, c.wantAction)
+			}
+			if (len(del) > 0) != c.wantDel {
+				t.Errorf("express2del = %q, wantDel = %v", del, c.wantDel)
+			}
+			if (len(add) > 0) != c.wantAdd {
+				t.Errorf("express2add = %q, wantAdd = %v", add, c.wantAdd)
+			}
+			if c.wantNoNewUUID && out.NewUUID != "" {
+				t.Errorf("NewUUID = %q, want empty for an action that wrote nothing", out.NewUUID)
+			}
+		})
+	}
+}
+
+// TestAcquireFormatSlotMismatch covers the check-and-increment guard in
+// acquireFormatSlot: once a host's concurrency limit is set by the first
+// FormatConfig entry to reach it, a later entry asking for a different
+// limit is a configuration conflict and must fail loudly rather than
+// silently racing against the first.
+func TestAcquireFormatSlotMismatch(t *testing.T) {
+	curveadm, err := cli.NewCurveAdm()
+	if err != nil {
+		t.Fatalf("cli.NewCurveAdm() error = %v", err)
+	}
+	host := "format-slot-mismatch-host"
+
+	if err := acquireFormatSlot(curveadm, host, 2)(nil); err != nil {
+		t.Fatalf("first acquireFormatSlot() error = %v", err)
+	}
+	defer releaseFormatSlot(curveadm, host, 2)(nil)
+
+	if err := acquireFormatSlot(curveadm, host, 3)(nil); err == nil {
+		t.Fatal("acquireFormatSlot() with a conflicting limit succeeded, want ERR_FORMAT_CONCURRENCY_LIMIT_MISMATCH")
+	}
+}
+
+// TestStep2FormatWithConcurrencyLimitReleasesOnError covers the defer in
+// step2FormatWithConcurrencyLimit.Execute: a slot acquired before the wrapped
+// steps run must be released even when one of them fails, or the host's
+// concurrency counter leaks and every subsequent format run against it
+// eventually blocks forever.
+func TestStep2FormatWithConcurrencyLimitReleasesOnError(t *testing.T) {
+	curveadm, err := cli.NewCurveAdm()
+	if err != nil {
+		t.Fatalf("cli.NewCurveAdm() error = %v", err)
+	}
+	host := "format-slot-release-on-error-host"
+	boom := errors.New("boom")
+
+	s := &step2FormatWithConcurrencyLimit{
+		host:     host,
+		limit:    1,
+		curveadm: curveadm,
+		steps: []task.Step{
+			&step.Lambda{Lambda: func(ctx *context.Context) error { return boom }},
+		},
+	}
+
+	if err := s.Execute(nil); !errors.Is(err, boom) {
+		t.Fatalf("Execute() error = %v, want %v", err, boom)
+	}
+
+	var count int
+	err = curveadm.MemStorage().TX(func(m *utils.SafeMap) error {
+		count, _ = m.Get(formatConcurrencyCountKey(host)).(int)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("MemStorage().TX() error = %v", err)
+	}
+	if count != 0 {
+		t.Errorf("slot count after a failing step = %d, want 0 (release must run on every exit path)", count)
+	}
+}